@@ -0,0 +1,93 @@
+package snek
+
+import "fmt"
+
+// PositionalArgs validates the positional arguments passed to a subcommand.
+// It is assigned to Def.Args, and invoked by Options.ParseFlags once flags
+// have been parsed.
+type PositionalArgs func(opt Options, args []string) error
+
+// NoArgs returns an error if any positional arguments are given.
+func NoArgs(opt Options, args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("unknown argument %q", args[0])
+	}
+	return nil
+}
+
+// ExactArgs returns a PositionalArgs that requires exactly n positional
+// arguments.
+func ExactArgs(n int) PositionalArgs {
+	return func(opt Options, args []string) error {
+		if len(args) != n {
+			return fmt.Errorf("accepts %d arg(s), received %d", n, len(args))
+		}
+		return nil
+	}
+}
+
+// MinimumNArgs returns a PositionalArgs that requires at least n positional
+// arguments.
+func MinimumNArgs(n int) PositionalArgs {
+	return func(opt Options, args []string) error {
+		if len(args) < n {
+			return fmt.Errorf("requires at least %d arg(s), received %d", n, len(args))
+		}
+		return nil
+	}
+}
+
+// MaximumNArgs returns a PositionalArgs that requires at most n positional
+// arguments.
+func MaximumNArgs(n int) PositionalArgs {
+	return func(opt Options, args []string) error {
+		if len(args) > n {
+			return fmt.Errorf("accepts at most %d arg(s), received %d", n, len(args))
+		}
+		return nil
+	}
+}
+
+// RangeArgs returns a PositionalArgs that requires between min and max
+// (inclusive) positional arguments.
+func RangeArgs(min, max int) PositionalArgs {
+	return func(opt Options, args []string) error {
+		if len(args) < min || len(args) > max {
+			return fmt.Errorf("accepts between %d and %d arg(s), received %d", min, max, len(args))
+		}
+		return nil
+	}
+}
+
+// OnlyValidArgs returns a PositionalArgs that requires every positional
+// argument to be present in valid.
+func OnlyValidArgs(valid []string) PositionalArgs {
+	return func(opt Options, args []string) error {
+		for _, arg := range args {
+			ok := false
+			for _, v := range valid {
+				if arg == v {
+					ok = true
+					break
+				}
+			}
+			if !ok {
+				return fmt.Errorf("invalid argument %q", arg)
+			}
+		}
+		return nil
+	}
+}
+
+// MatchAll returns a PositionalArgs that requires every one of validators to
+// pass, in order, stopping at the first error.
+func MatchAll(validators ...PositionalArgs) PositionalArgs {
+	return func(opt Options, args []string) error {
+		for _, validate := range validators {
+			if err := validate(opt, args); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,44 @@
+package snek
+
+import "testing"
+
+func TestPositionalArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    PositionalArgs
+		in      []string
+		wantErr bool
+	}{
+		{"NoArgs ok", NoArgs, nil, false},
+		{"NoArgs fail", NoArgs, []string{"a"}, true},
+
+		{"ExactArgs ok", ExactArgs(2), []string{"a", "b"}, false},
+		{"ExactArgs too few", ExactArgs(2), []string{"a"}, true},
+		{"ExactArgs too many", ExactArgs(2), []string{"a", "b", "c"}, true},
+
+		{"MinimumNArgs ok", MinimumNArgs(2), []string{"a", "b", "c"}, false},
+		{"MinimumNArgs fail", MinimumNArgs(2), []string{"a"}, true},
+
+		{"MaximumNArgs ok", MaximumNArgs(2), []string{"a"}, false},
+		{"MaximumNArgs fail", MaximumNArgs(2), []string{"a", "b", "c"}, true},
+
+		{"RangeArgs ok", RangeArgs(1, 2), []string{"a"}, false},
+		{"RangeArgs too few", RangeArgs(1, 2), nil, true},
+		{"RangeArgs too many", RangeArgs(1, 2), []string{"a", "b", "c"}, true},
+
+		{"OnlyValidArgs ok", OnlyValidArgs([]string{"add", "list"}), []string{"add"}, false},
+		{"OnlyValidArgs fail", OnlyValidArgs([]string{"add", "list"}), []string{"bogus"}, true},
+
+		{"MatchAll ok", MatchAll(MinimumNArgs(1), MaximumNArgs(2)), []string{"a"}, false},
+		{"MatchAll fail on first", MatchAll(MinimumNArgs(1), MaximumNArgs(2)), nil, true},
+		{"MatchAll fail on second", MatchAll(MinimumNArgs(1), MaximumNArgs(2)), []string{"a", "b", "c"}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.args(Options{}, test.in)
+			if (err != nil) != test.wantErr {
+				t.Errorf("got err = %v, wantErr = %v", err, test.wantErr)
+			}
+		})
+	}
+}
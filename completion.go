@@ -0,0 +1,223 @@
+package snek
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// flagCompletions maps a flag name to a function producing completions for
+// its value.
+type flagCompletions map[string]func(toComplete string) []string
+
+// commandFlagSet is the concrete type passed to FlagSetter.SetFlags. It wraps
+// a flag.FlagSet so that it continues to satisfy the FlagSet interface, while
+// also recording completions registered through RegisterFlagCompletion so
+// that the __complete subcommand can find them afterward.
+type commandFlagSet struct {
+	*flag.FlagSet
+	completions flagCompletions
+}
+
+// newFlagSet returns a commandFlagSet ready to be populated by a command's
+// SetFlags method.
+func newFlagSet(name string) *commandFlagSet {
+	return &commandFlagSet{
+		FlagSet:     flag.NewFlagSet(name, flag.ContinueOnError),
+		completions: flagCompletions{},
+	}
+}
+
+// RegisterFlagCompletion implements FlagSet.
+func (fs *commandFlagSet) RegisterFlagCompletion(name string, fn func(toComplete string) []string) {
+	fs.completions[name] = fn
+}
+
+// completionDef is the definition for the built-in completion command.
+var completionDef = Def{
+	Name:      "completion",
+	Summary:   "Generate shell completion scripts.",
+	Arguments: "<bash|zsh|fish|powershell>",
+	Description: `Generates a shell completion script for the given shell,
+printed to standard output. The script can be loaded by the shell to enable
+completion of subcommand names, flags, and, where the shell supports it,
+dynamic arguments.`,
+	New: func() Command { return &completionCommand{} },
+}
+
+// completionCommand is the implementation of the built-in completion
+// command.
+type completionCommand struct{}
+
+func (c *completionCommand) Run(opt Options) error {
+	if err := opt.ParseFlags(); err != nil {
+		return err
+	}
+	shell := opt.Arg(0)
+	if shell == "" {
+		return fmt.Errorf("missing shell argument")
+	}
+	return generateCompletion(opt.Input, opt.registry, shell, opt.Stdout)
+}
+
+// completeDef is the definition for the hidden completion helper command,
+// invoked by generated completion scripts to compute dynamic completions.
+var completeDef = Def{
+	Name:    "__complete",
+	Summary: "Generate a list of completions for the given arguments.",
+	Hidden:  true,
+	New:     func() Command { return completeCommand{} },
+}
+
+// completeCommand is the implementation of the hidden __complete command.
+//
+// Its arguments are the words of the command line being completed (excluding
+// the program name), with the final word being the (possibly empty) portion
+// currently being typed. One completion candidate is printed per line.
+type completeCommand struct{}
+
+func (c completeCommand) Run(opt Options) error {
+	args := opt.Arguments
+	if len(args) == 0 {
+		return nil
+	}
+	toComplete := args[len(args)-1]
+	args = args[:len(args)-1]
+	for _, candidate := range complete(opt.Input, opt.registry, args, toComplete) {
+		fmt.Fprintln(opt.Stdout, candidate)
+	}
+	return nil
+}
+
+// complete returns completion candidates for toComplete, given the preceding
+// words args of an in-progress command line.
+func complete(i Input, r registry, args []string, toComplete string) []string {
+	if len(args) == 0 {
+		return matchNames(r.List(), toComplete)
+	}
+	def, _, rest, ok := resolve(r, args[0], args[1:])
+	if !ok {
+		return nil
+	}
+	fs := newFlagSet(i.Program)
+	cmd := def.New()
+	if setter, ok := cmd.(FlagSetter); ok {
+		setter.SetFlags(fs)
+	}
+	if len(rest) > 0 {
+		if last := rest[len(rest)-1]; strings.HasPrefix(last, "-") {
+			if fn, ok := fs.completions[strings.TrimLeft(last, "-")]; ok {
+				return fn(toComplete)
+			}
+		}
+	}
+	if strings.HasPrefix(toComplete, "-") {
+		var names []string
+		fs.VisitAll(func(f *flag.Flag) {
+			name := "-" + f.Name
+			if strings.HasPrefix(name, toComplete) {
+				names = append(names, name)
+			}
+		})
+		return names
+	}
+	if len(def.Subcommands) > 0 {
+		return matchNames(def.Subcommands, toComplete)
+	}
+	if def.CompleteArgs == nil {
+		return nil
+	}
+	opt := Options{commandFlagSet: fs, Input: i, registry: r, Def: def}
+	return def.CompleteArgs(opt, rest, toComplete)
+}
+
+// matchNames returns the names of the non-hidden commands in list that start
+// with prefix.
+func matchNames(list []Def, prefix string) []string {
+	var names []string
+	for _, def := range list {
+		if def.Hidden {
+			continue
+		}
+		if strings.HasPrefix(def.Name, prefix) {
+			names = append(names, def.Name)
+		}
+	}
+	return names
+}
+
+// GenerateCompletion writes to w a completion script for the given shell.
+// Supported values of shell are "bash", "zsh", "fish", and "powershell".
+func (p *Program) GenerateCompletion(shell string, w io.Writer) error {
+	return generateCompletion(p.Input, p.registry, shell, w)
+}
+
+func generateCompletion(i Input, r registry, shell string, w io.Writer) error {
+	switch shell {
+	case "bash":
+		return genBashCompletion(i, w)
+	case "zsh":
+		return genZshCompletion(i, w)
+	case "fish":
+		return genFishCompletion(i, w)
+	case "powershell":
+		return genPowerShellCompletion(i, w)
+	default:
+		return fmt.Errorf("unsupported shell %q", shell)
+	}
+}
+
+func genBashCompletion(i Input, w io.Writer) error {
+	const tmpl = `# bash completion for %[1]s
+_%[1]s_complete() {
+	local cur words
+	cur="${COMP_WORDS[COMP_CWORD]}"
+	words=("${COMP_WORDS[@]:1:COMP_CWORD-1}")
+	COMPREPLY=($(%[1]s __complete "${words[@]}" "$cur"))
+}
+complete -F _%[1]s_complete %[1]s
+`
+	_, err := fmt.Fprintf(w, tmpl, i.Program)
+	return err
+}
+
+func genZshCompletion(i Input, w io.Writer) error {
+	const tmpl = `#compdef %[1]s
+_%[1]s_complete() {
+	local cur words
+	cur="${words[CURRENT]}"
+	local candidates=("${(@f)$(%[1]s __complete "${words[2,CURRENT-1]}" "$cur")}")
+	compadd -a candidates
+}
+compdef _%[1]s_complete %[1]s
+`
+	_, err := fmt.Fprintf(w, tmpl, i.Program)
+	return err
+}
+
+func genFishCompletion(i Input, w io.Writer) error {
+	const tmpl = `# fish completion for %[1]s
+function __%[1]s_complete
+	set -l tokens (commandline -opc) (commandline -ct)
+	%[1]s __complete $tokens[2..-1]
+end
+complete -c %[1]s -f -a '(__%[1]s_complete)'
+`
+	_, err := fmt.Fprintf(w, tmpl, i.Program)
+	return err
+}
+
+func genPowerShellCompletion(i Input, w io.Writer) error {
+	const tmpl = `# PowerShell completion for %[1]s
+Register-ArgumentCompleter -Native -CommandName %[1]s -ScriptBlock {
+	param($wordToComplete, $commandAst, $cursorPosition)
+	$words = $commandAst.CommandElements | Select-Object -Skip 1 | ForEach-Object { $_.ToString() }
+	& %[1]s __complete @words $wordToComplete | ForEach-Object {
+		[System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+	}
+}
+`
+	_, err := fmt.Fprintf(w, tmpl, i.Program)
+	return err
+}
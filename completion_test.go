@@ -0,0 +1,105 @@
+package snek
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+type flagCmd struct{}
+
+func (flagCmd) SetFlags(f FlagSet) {
+	f.Bool("force", false, "Force.")
+	f.String("branch", "", "Branch name.")
+	f.RegisterFlagCompletion("branch", func(toComplete string) []string {
+		return matchPrefix([]string{"main", "master", "mybranch"}, toComplete)
+	})
+}
+
+func (flagCmd) Run(Options) error { return nil }
+
+type argCompleteCmd struct{}
+
+func (argCompleteCmd) Run(Options) error { return nil }
+
+func matchPrefix(candidates []string, prefix string) []string {
+	var out []string
+	for _, c := range candidates {
+		if len(c) >= len(prefix) && c[:len(prefix)] == prefix {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func completeTestRegistry() registry {
+	return registry{
+		"remote": Def{
+			Name: "remote",
+			New:  newCmd,
+			Subcommands: []Def{
+				{Name: "add", New: func() Command { return flagCmd{} }},
+				{Name: "list", New: newCmd},
+			},
+		},
+		"checkout": Def{
+			Name: "checkout",
+			New:  func() Command { return argCompleteCmd{} },
+			CompleteArgs: func(opt Options, args []string, toComplete string) []string {
+				return matchPrefix([]string{"main", "master"}, toComplete)
+			},
+		},
+	}
+}
+
+func TestCompleteTopLevelNames(t *testing.T) {
+	got := complete(Input{Program: "prog"}, completeTestRegistry(), nil, "rem")
+	sort.Strings(got)
+	want := []string{"remote"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestCompleteSubcommandNames(t *testing.T) {
+	got := complete(Input{Program: "prog"}, completeTestRegistry(), []string{"remote"}, "")
+	sort.Strings(got)
+	want := []string{"add", "list"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestCompleteFlagNames(t *testing.T) {
+	got := complete(Input{Program: "prog"}, completeTestRegistry(), []string{"remote", "add"}, "-br")
+	sort.Strings(got)
+	want := []string{"-branch"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestCompleteRegisteredFlagValue(t *testing.T) {
+	got := complete(Input{Program: "prog"}, completeTestRegistry(), []string{"remote", "add", "-branch"}, "ma")
+	sort.Strings(got)
+	want := []string{"main", "master"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestCompletePositionalArgs(t *testing.T) {
+	got := complete(Input{Program: "prog"}, completeTestRegistry(), []string{"checkout"}, "ma")
+	sort.Strings(got)
+	want := []string{"main", "master"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestCompleteUnknownCommand(t *testing.T) {
+	got := complete(Input{Program: "prog"}, completeTestRegistry(), []string{"bogus"}, "")
+	if got != nil {
+		t.Errorf("got %v, want nil", got)
+	}
+}
@@ -0,0 +1,328 @@
+// Package doc generates Markdown and man page documentation for a
+// snek.Program, including any nested subcommand tree registered through
+// Program.RegisterGroup.
+//
+// The generator only instantiates commands through Def.New in order to
+// introspect their flags; it never executes a command.
+package doc
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Anaminus/snek"
+)
+
+// flagSet adapts a *flag.FlagSet to satisfy snek.FlagSet, so that a
+// command's SetFlags can be called purely to introspect its flags.
+type flagSet struct {
+	*flag.FlagSet
+}
+
+func (flagSet) RegisterFlagCompletion(name string, fn func(toComplete string) []string) {}
+
+func newFlagSet() flagSet {
+	return flagSet{flag.NewFlagSet("", flag.ContinueOnError)}
+}
+
+// node is a single command resolved while walking a Program's tree.
+type node struct {
+	def  snek.Def
+	path []string
+}
+
+func (n node) fullName(program string) string {
+	return program + " " + strings.Join(n.path, " ")
+}
+
+func (n node) fileBase() string {
+	return strings.Join(n.path, "_")
+}
+
+// walk calls fn for every non-hidden command registered on p, including
+// nested subcommands, depth-first in name order.
+func walk(p *snek.Program, fn func(n node)) {
+	var visit func(defs []snek.Def, path []string)
+	visit = func(defs []snek.Def, path []string) {
+		list := append([]snek.Def{}, defs...)
+		sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+		for _, def := range list {
+			if def.Hidden {
+				continue
+			}
+			childPath := append(append([]string{}, path...), def.Name)
+			fn(node{def: def, path: childPath})
+			if len(def.Subcommands) > 0 {
+				visit(def.Subcommands, childPath)
+			}
+		}
+	}
+	visit(p.List(), nil)
+}
+
+// siblings returns the list of definitions registered alongside the command
+// at path (i.e. its parent's Subcommands, or the top-level registry for a
+// path of length 1).
+func siblings(p *snek.Program, path []string) []snek.Def {
+	defs := p.List()
+	for _, name := range path[:len(path)-1] {
+		found := false
+		for _, d := range defs {
+			if d.Name == name {
+				defs = d.Subcommands
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil
+		}
+	}
+	return defs
+}
+
+// collectFlags instantiates def.New and, if it implements snek.FlagSetter,
+// returns its declared flags. It does not execute the command.
+func collectFlags(def snek.Def) []*flag.Flag {
+	cmd := def.New()
+	setter, ok := cmd.(snek.FlagSetter)
+	if !ok {
+		return nil
+	}
+	fs := newFlagSet()
+	setter.SetFlags(fs)
+	var flags []*flag.Flag
+	fs.VisitAll(func(f *flag.Flag) { flags = append(flags, f) })
+	return flags
+}
+
+// GenMarkdown walks p's command tree and writes one Markdown file per
+// command to dir, named after the command's full path (e.g. "remote_add.md"
+// for a command registered as "remote add").
+func GenMarkdown(p *snek.Program, dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	var err error
+	walk(p, func(n node) {
+		if err != nil {
+			return
+		}
+		err = genMarkdownFile(p, n, dir)
+	})
+	return err
+}
+
+func genMarkdownFile(p *snek.Program, n node, dir string) error {
+	name := n.fullName(p.Program)
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "## %s\n\n", name)
+	if n.def.Summary != "" {
+		fmt.Fprintf(&buf, "%s\n\n", n.def.Summary)
+	}
+
+	fmt.Fprintf(&buf, "### Synopsis\n\n```\n%s", name)
+	if n.def.Arguments != "" {
+		fmt.Fprintf(&buf, " %s", n.def.Arguments)
+	}
+	fmt.Fprintf(&buf, "\n```\n\n")
+
+	if n.def.Description != "" {
+		fmt.Fprintf(&buf, "%s\n\n", strings.TrimSpace(n.def.Description))
+	}
+
+	if flags := collectFlags(n.def); len(flags) > 0 {
+		fmt.Fprintf(&buf, "### Flags\n\n| Name | Default | Usage |\n| --- | --- | --- |\n")
+		for _, f := range flags {
+			fmt.Fprintf(&buf, "| `-%s` | `%s` | %s |\n", f.Name, f.DefValue, f.Usage)
+		}
+		fmt.Fprintf(&buf, "\n")
+	}
+
+	if see := seeAlsoMarkdown(p, n); see != "" {
+		fmt.Fprintf(&buf, "### See also\n\n%s\n", see)
+	}
+
+	return os.WriteFile(filepath.Join(dir, n.fileBase()+".md"), buf.Bytes(), 0o644)
+}
+
+func seeAlsoMarkdown(p *snek.Program, n node) string {
+	var lines []string
+	for _, d := range siblings(p, n.path) {
+		if d.Hidden || d.Name == n.def.Name {
+			continue
+		}
+		path := append(append([]string{}, n.path[:len(n.path)-1]...), d.Name)
+		lines = append(lines, fmt.Sprintf("* [%s %s](%s.md) - %s", p.Program, strings.Join(path, " "), strings.Join(path, "_"), d.Summary))
+	}
+	for _, d := range n.def.Subcommands {
+		if d.Hidden {
+			continue
+		}
+		path := append(append([]string{}, n.path...), d.Name)
+		lines = append(lines, fmt.Sprintf("* [%s %s](%s.md) - %s", p.Program, strings.Join(path, " "), strings.Join(path, "_"), d.Summary))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ManHeader supplies the page header metadata used by GenManTree. Any zero
+// fields are rendered as empty strings, except Section, which defaults to
+// "1".
+type ManHeader struct {
+	// Source identifies the project or organization the manual comes from
+	// (the .TH "source" field).
+	Source string
+
+	// Manual is the title of the manual the page belongs to (the .TH
+	// "manual" field).
+	Manual string
+
+	// Section is the man page section, e.g. "1" for user commands. Defaults
+	// to "1".
+	Section string
+
+	// Date is the page's publication date. If nil, the current date is
+	// omitted from the header.
+	Date *time.Time
+}
+
+// GenManTree walks p's command tree and writes one roff man page per
+// command to dir, named after the command's full path (e.g. "remote_add.1"
+// for a command registered as "remote add").
+func GenManTree(p *snek.Program, header *ManHeader, dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	if header == nil {
+		header = &ManHeader{}
+	}
+	section := header.Section
+	if section == "" {
+		section = "1"
+	}
+	var err error
+	walk(p, func(n node) {
+		if err != nil {
+			return
+		}
+		err = genManFile(p, header, section, n, dir)
+	})
+	return err
+}
+
+func genManFile(p *snek.Program, header *ManHeader, section string, n node, dir string) error {
+	name := n.fullName(p.Program)
+	var date string
+	if header.Date != nil {
+		date = header.Date.Format("Jan 2006")
+	}
+	var buf bytes.Buffer
+
+	title := strings.ToUpper(strings.ReplaceAll(name, " ", "-"))
+	fmt.Fprintf(&buf, ".TH %q %q %q %q %q\n", title, section, date, header.Source, header.Manual)
+
+	fmt.Fprintf(&buf, ".SH NAME\n%s", name)
+	if n.def.Summary != "" {
+		fmt.Fprintf(&buf, " \\- %s", n.def.Summary)
+	}
+	fmt.Fprintf(&buf, "\n")
+
+	fmt.Fprintf(&buf, ".SH SYNOPSIS\n\\fB%s\\fR", name)
+	if n.def.Arguments != "" {
+		fmt.Fprintf(&buf, " %s", n.def.Arguments)
+	}
+	fmt.Fprintf(&buf, "\n")
+
+	if n.def.Description != "" {
+		fmt.Fprintf(&buf, ".SH DESCRIPTION\n%s\n", strings.TrimSpace(n.def.Description))
+	}
+
+	if flags := collectFlags(n.def); len(flags) > 0 {
+		fmt.Fprintf(&buf, ".SH OPTIONS\n")
+		for _, f := range flags {
+			fmt.Fprintf(&buf, ".TP\n\\-%s\n%s\n", f.Name, f.Usage)
+		}
+	}
+
+	if see := seeAlsoMan(p, n); see != "" {
+		fmt.Fprintf(&buf, ".SH SEE ALSO\n%s\n", see)
+	}
+
+	return os.WriteFile(filepath.Join(dir, n.fileBase()+"."+section), buf.Bytes(), 0o644)
+}
+
+func seeAlsoMan(p *snek.Program, n node) string {
+	var names []string
+	for _, d := range siblings(p, n.path) {
+		if d.Hidden || d.Name == n.def.Name {
+			continue
+		}
+		path := append(append([]string{}, n.path[:len(n.path)-1]...), d.Name)
+		names = append(names, strings.Join(path, "-"))
+	}
+	for _, d := range n.def.Subcommands {
+		if d.Hidden {
+			continue
+		}
+		path := append(append([]string{}, n.path...), d.Name)
+		names = append(names, strings.Join(path, "-"))
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	return strings.Join(names, ", ")
+}
+
+// WithDocGen registers "gen-docs" and "gen-man" subcommands on p, which
+// generate Markdown and man page documentation (respectively) for p to the
+// directory given as the command's sole argument. This is opt-in, since
+// most programs built on snek won't want to ship a documentation
+// generator.
+func WithDocGen(p *snek.Program) *snek.Program {
+	p.Register(snek.Def{
+		Name:        "gen-docs",
+		Summary:     "Generate Markdown documentation.",
+		Arguments:   "<dir>",
+		Description: "Writes one Markdown file per command, rooted at dir.",
+		Args:        snek.ExactArgs(1),
+		New:         func() snek.Command { return genDocsCommand{program: p} },
+	})
+	p.Register(snek.Def{
+		Name:        "gen-man",
+		Summary:     "Generate man pages.",
+		Arguments:   "<dir>",
+		Description: "Writes one roff man page per command, rooted at dir.",
+		Args:        snek.ExactArgs(1),
+		New:         func() snek.Command { return genManCommand{program: p} },
+	})
+	return p
+}
+
+type genDocsCommand struct {
+	program *snek.Program
+}
+
+func (c genDocsCommand) Run(opt snek.Options) error {
+	if err := opt.ParseFlags(); err != nil {
+		return err
+	}
+	return GenMarkdown(c.program, opt.Arg(0))
+}
+
+type genManCommand struct {
+	program *snek.Program
+}
+
+func (c genManCommand) Run(opt snek.Options) error {
+	if err := opt.ParseFlags(); err != nil {
+		return err
+	}
+	return GenManTree(c.program, nil, opt.Arg(0))
+}
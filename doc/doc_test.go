@@ -0,0 +1,102 @@
+package doc
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Anaminus/snek"
+)
+
+type addCmd struct{}
+
+func (addCmd) SetFlags(f snek.FlagSet) {
+	f.Bool("force", false, "Force the add.")
+}
+
+func (addCmd) Run(snek.Options) error { return nil }
+
+func testProgram() *snek.Program {
+	p := snek.NewProgram("prog", nil)
+	p.RegisterGroup(snek.Def{
+		Name:    "remote",
+		Summary: "Manage remotes.",
+	}, snek.Def{
+		Name:        "add",
+		Summary:     "Add a remote.",
+		Description: "Adds a new remote with the given name and URL.",
+		Arguments:   "<name> <url>",
+		Args:        snek.ExactArgs(2),
+		New:         func() snek.Command { return addCmd{} },
+	})
+	return p
+}
+
+func TestGenMarkdown(t *testing.T) {
+	dir := t.TempDir()
+	if err := GenMarkdown(testProgram(), dir); err != nil {
+		t.Fatalf("GenMarkdown: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "remote_add.md"))
+	if err != nil {
+		t.Fatalf("reading remote_add.md: %v", err)
+	}
+	content := string(data)
+
+	for _, want := range []string{
+		"## prog remote add",
+		"Add a remote.",
+		"```\nprog remote add <name> <url>\n```",
+		"Adds a new remote with the given name and URL.",
+		"| `-force` | `false` | Force the add. |",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("remote_add.md missing %q\ngot:\n%s", want, content)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "remote.md")); err != nil {
+		t.Errorf("expected remote.md to be generated: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "help.md")); err != nil {
+		t.Errorf("expected help.md to be generated: %v", err)
+	}
+}
+
+func TestGenManTree(t *testing.T) {
+	dir := t.TempDir()
+	header := &ManHeader{Source: "Snek", Manual: "Snek Manual"}
+	if err := GenManTree(testProgram(), header, dir); err != nil {
+		t.Fatalf("GenManTree: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "remote_add.1"))
+	if err != nil {
+		t.Fatalf("reading remote_add.1: %v", err)
+	}
+	content := string(data)
+
+	for _, want := range []string{
+		`.TH "PROG-REMOTE-ADD" "1"`,
+		".SH NAME\nprog remote add \\- Add a remote.",
+		".SH SYNOPSIS\n\\fBprog remote add\\fR <name> <url>",
+		".SH DESCRIPTION\nAdds a new remote with the given name and URL.",
+		".SH OPTIONS\n.TP\n\\-force\nForce the add.",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("remote_add.1 missing %q\ngot:\n%s", want, content)
+		}
+	}
+}
+
+func TestWithDocGen(t *testing.T) {
+	p := WithDocGen(testProgram())
+	if !p.Has("gen-docs") {
+		t.Errorf("expected gen-docs to be registered")
+	}
+	if !p.Has("gen-man") {
+		t.Errorf("expected gen-man to be registered")
+	}
+}
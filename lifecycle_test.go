@@ -0,0 +1,130 @@
+package snek
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"testing"
+)
+
+// discardFile is a minimal WriteFile that discards everything written to it.
+type discardFile struct{}
+
+func (discardFile) Stat() (fs.FileInfo, error)  { return nil, nil }
+func (discardFile) Read([]byte) (int, error)    { return 0, nil }
+func (discardFile) Close() error                { return nil }
+func (discardFile) Write(p []byte) (int, error) { return len(p), nil }
+
+func newLifecycleProgram(cmd Command) *Program {
+	p := NewProgram("prog", []string{"prog", "run"})
+	p.Stdout = discardFile{}
+	p.Stderr = discardFile{}
+	p.Register(Def{Name: "run", New: func() Command { return cmd }})
+	return p
+}
+
+type errCommand struct{ err error }
+
+func (c errCommand) Run(Options) error { return c.err }
+
+func TestResolveExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func(error) int
+		err  error
+		want int
+	}{
+		{"default success", nil, nil, 0},
+		{"default failure", nil, errors.New("boom"), 1},
+		{"custom mapping", func(err error) int {
+			if err == nil {
+				return 0
+			}
+			return 42
+		}, errors.New("boom"), 42},
+	}
+	for _, test := range tests {
+		p := NewProgram("prog", nil)
+		if test.fn != nil {
+			p.ExitCode(test.fn)
+		}
+		if got := p.resolveExitCode(test.err); got != test.want {
+			t.Errorf("%s: resolveExitCode(%v) = %d, want %d", test.name, test.err, got, test.want)
+		}
+	}
+}
+
+// trackedCommand records whether its Run method was invoked.
+type trackedCommand struct{ ran *bool }
+
+func (c trackedCommand) Run(Options) error {
+	*c.ran = true
+	return nil
+}
+
+func TestBeforeShortCircuits(t *testing.T) {
+	wantErr := errors.New("denied")
+	var ran bool
+	p := NewProgram("prog", []string{"prog", "run"})
+	p.Stdout = discardFile{}
+	p.Stderr = discardFile{}
+	p.Register(Def{Name: "run", New: func() Command { return trackedCommand{ran: &ran} }})
+	p.Before(func(ctx context.Context, opt Options) error {
+		return wantErr
+	})
+	err := p.Run("run")
+	if err != wantErr {
+		t.Fatalf("Run() = %v, want %v", err, wantErr)
+	}
+	if ran {
+		t.Fatalf("command's Run method was invoked despite Before returning an error")
+	}
+}
+
+func TestAfterRunsAfterFailureAndTransformsError(t *testing.T) {
+	cmdErr := errors.New("command failed")
+	transformed := errors.New("transformed")
+	var afterErr error
+	var afterCalled bool
+	p := newLifecycleProgram(errCommand{err: cmdErr})
+	p.After(func(ctx context.Context, opt Options, err error) error {
+		afterCalled = true
+		afterErr = err
+		return transformed
+	})
+	got := p.Run("run")
+	if !afterCalled {
+		t.Fatalf("After was not called")
+	}
+	if afterErr != cmdErr {
+		t.Fatalf("After received err = %v, want %v", afterErr, cmdErr)
+	}
+	if got != transformed {
+		t.Fatalf("Run() = %v, want %v", got, transformed)
+	}
+}
+
+func TestAfterRunsWhenBeforeShortCircuits(t *testing.T) {
+	beforeErr := errors.New("denied")
+	var afterErr error
+	var afterCalled bool
+	p := newLifecycleProgram(errCommand{})
+	p.Before(func(ctx context.Context, opt Options) error {
+		return beforeErr
+	})
+	p.After(func(ctx context.Context, opt Options, err error) error {
+		afterCalled = true
+		afterErr = err
+		return err
+	})
+	got := p.Run("run")
+	if !afterCalled {
+		t.Fatalf("After was not called when Before short-circuited")
+	}
+	if afterErr != beforeErr {
+		t.Fatalf("After received err = %v, want %v", afterErr, beforeErr)
+	}
+	if got != beforeErr {
+		t.Fatalf("Run() = %v, want %v", got, beforeErr)
+	}
+}
@@ -0,0 +1,104 @@
+package snek
+
+import (
+	"reflect"
+	"testing"
+)
+
+func newCmd() Command { return noopCommand{} }
+
+type noopCommand struct{}
+
+func (noopCommand) Run(Options) error { return nil }
+
+func TestResolve(t *testing.T) {
+	r := registry{
+		"remote": Def{
+			Name: "remote",
+			New:  newCmd,
+			Subcommands: []Def{
+				{Name: "add", New: newCmd},
+				{Name: "list", New: newCmd},
+			},
+		},
+		"status": Def{Name: "status", New: newCmd},
+	}
+
+	tests := []struct {
+		name     string
+		args     []string
+		wantName string
+		wantPath []string
+		wantRest []string
+		wantOk   bool
+	}{
+		{"status", nil, "status", []string{"status"}, nil, true},
+		{"remote", nil, "remote", []string{"remote"}, nil, true},
+		{"remote", []string{"add", "origin"}, "add", []string{"remote", "add"}, []string{"origin"}, true},
+		{"remote", []string{"bogus"}, "remote", []string{"remote"}, []string{"bogus"}, true},
+		{"bogus", nil, "", nil, nil, false},
+	}
+	for _, test := range tests {
+		def, path, rest, ok := resolve(r, test.name, test.args)
+		if ok != test.wantOk {
+			t.Errorf("resolve(%q, %v): ok = %v, want %v", test.name, test.args, ok, test.wantOk)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if def.Name != test.wantName {
+			t.Errorf("resolve(%q, %v): def.Name = %q, want %q", test.name, test.args, def.Name, test.wantName)
+		}
+		if !reflect.DeepEqual(path, test.wantPath) {
+			t.Errorf("resolve(%q, %v): path = %v, want %v", test.name, test.args, path, test.wantPath)
+		}
+		if !reflect.DeepEqual(rest, test.wantRest) {
+			t.Errorf("resolve(%q, %v): rest = %v, want %v", test.name, test.args, rest, test.wantRest)
+		}
+	}
+}
+
+func TestRegisterGroupValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		parent  Def
+		childen []Def
+	}{
+		{"empty parent name", Def{}, []Def{{Name: "add", New: newCmd}}},
+		{"empty child name", Def{Name: "remote"}, []Def{{New: newCmd}}},
+		{"nil child New", Def{Name: "remote"}, []Def{{Name: "add"}}},
+		{"duplicate child names", Def{Name: "remote"}, []Def{
+			{Name: "add", New: newCmd},
+			{Name: "add", New: newCmd},
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("RegisterGroup did not panic")
+				}
+			}()
+			p := NewProgram("prog", []string{"prog"})
+			p.RegisterGroup(test.parent, test.childen...)
+		})
+	}
+}
+
+func TestRegisterGroupValid(t *testing.T) {
+	defer func() {
+		if r := recover(); r != nil {
+			t.Fatalf("RegisterGroup panicked: %v", r)
+		}
+	}()
+	p := NewProgram("prog", []string{"prog"})
+	p.RegisterGroup(Def{Name: "remote"}, Def{Name: "add", New: newCmd}, Def{Name: "list", New: newCmd})
+	if !p.registry.Has("remote") {
+		t.Fatalf("expected remote to be registered")
+	}
+	def, path, _, ok := resolve(p.registry, "remote", []string{"add"})
+	if !ok || def.Name != "add" || !reflect.DeepEqual(path, []string{"remote", "add"}) {
+		t.Fatalf("resolve did not find nested child: def=%v path=%v ok=%v", def, path, ok)
+	}
+}
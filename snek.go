@@ -3,54 +3,56 @@
 //
 // An example of a main entry point:
 //
-//     var Program = snek.NewProgram("", os.Args)
+//	var Program = snek.NewProgram("", os.Args)
 //
-//     func main() {
-//     	Program.Main()
-//     }
+//	func main() {
+//		Program.Main()
+//	}
 //
 // An example of a subcommand:
 //
-//     func init() {
-//     	Program.Register(snek.Def{
-//     		Name:        "echo",
-//     		Summary:     "Display text.",
-//     		Arguments:   "[-n] [TEXT...]",
-//     		Description: `Write the given arguments to standard output.`,
-//     		New:         func() snek.Command { return &EchoCommand{} },
-//     	})
-//     }
+//	func init() {
+//		Program.Register(snek.Def{
+//			Name:        "echo",
+//			Summary:     "Display text.",
+//			Arguments:   "[-n] [TEXT...]",
+//			Description: `Write the given arguments to standard output.`,
+//			New:         func() snek.Command { return &EchoCommand{} },
+//		})
+//	}
 //
-//     type EchoCommand struct {
-//     	NoNewline bool
-//     }
+//	type EchoCommand struct {
+//		NoNewline bool
+//	}
 //
-//     func (c *EchoCommand) SetFlags(flags snek.FlagSet) {
-//     	flags.BoolVar(&c.NoNewline, "n", false, "Suppress trailing newline.")
-//     }
-//
-//     func (c *EchoCommand) Run(opt snek.Options) error {
-//     	if err := opt.ParseFlags(); err != nil {
-//     		return err
-//     	}
-//     	out := strings.Join(opt.Args(), " ")
-//     	fmt.Print(out)
-//     	if !c.NoNewline {
-//     		fmt.Print("\n")
-//     	}
-//     	return nil
-//     }
+//	func (c *EchoCommand) SetFlags(flags snek.FlagSet) {
+//		flags.BoolVar(&c.NoNewline, "n", false, "Suppress trailing newline.")
+//	}
 //
+//	func (c *EchoCommand) Run(opt snek.Options) error {
+//		if err := opt.ParseFlags(); err != nil {
+//			return err
+//		}
+//		out := strings.Join(opt.Args(), " ")
+//		fmt.Print(out)
+//		if !c.NoNewline {
+//			fmt.Print("\n")
+//		}
+//		return nil
+//	}
 package snek
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"io"
 	"io/fs"
 	"os"
+	"os/signal"
 	"sort"
 	"strings"
+	"syscall"
 	"time"
 )
 
@@ -58,6 +60,10 @@ import (
 type Program struct {
 	Input
 	registry
+
+	before   func(context.Context, Options) error
+	after    func(context.Context, Options, error) error
+	exitCode func(error) int
 }
 
 // NewProgram returns a Program initialized with the given raw arguments (e.g.
@@ -70,7 +76,11 @@ type Program struct {
 // this command can be removed with the NoHelp method.
 func NewProgram(name string, args []string) *Program {
 	program := Program{
-		registry: registry{"help": helpDef},
+		registry: registry{
+			"help":       helpDef,
+			"completion": completionDef,
+			"__complete": completeDef,
+		},
 	}
 	program.Program = name
 	if len(args) > 0 {
@@ -91,6 +101,30 @@ func (p *Program) Usage(usage string) *Program {
 	return p
 }
 
+// Before registers a hook that runs before every subcommand. If fn returns
+// an error, the subcommand is not run, and the error is passed through
+// After (if registered) as if the subcommand itself had returned it.
+func (p *Program) Before(fn func(context.Context, Options) error) *Program {
+	p.before = fn
+	return p
+}
+
+// After registers a hook that runs after every subcommand, including when
+// Before short-circuited it. err is the error returned by the subcommand
+// (or by Before); the error returned by fn replaces it.
+func (p *Program) After(fn func(context.Context, Options, error) error) *Program {
+	p.after = fn
+	return p
+}
+
+// ExitCode registers a function that maps the error returned by a
+// subcommand (nil on success) to a process exit code. If unset, Main exits
+// with 0 on success and 1 otherwise.
+func (p *Program) ExitCode(fn func(error) int) *Program {
+	p.exitCode = fn
+	return p
+}
+
 // writeUsage writes the GlobalUsage message to w, or Stderr if w is nil.
 func writeUsage(w io.Writer, i Input, r registry) {
 	if w == nil {
@@ -99,7 +133,7 @@ func writeUsage(w io.Writer, i Input, r registry) {
 		}
 	}
 	var summaries strings.Builder
-	r.WriteSummary(&summaries)
+	r.WriteSummary(&summaries, i.width())
 	globalUsage := i.GlobalUsage
 	if globalUsage == "" {
 		globalUsage = "Usage: %s <command>\n\nThe following commands are available:\n%s"
@@ -118,6 +152,13 @@ func (p *Program) NoHelp() *Program {
 	return p
 }
 
+// NoCompletion unregisters the "completion" and "__complete" subcommands.
+func (p *Program) NoCompletion() *Program {
+	delete(p.registry, "completion")
+	delete(p.registry, "__complete")
+	return p
+}
+
 // Register registers a subcommand under def.Name. Panics if def.Name is empty,
 // if def.New is nil, or if a subcommand was already registered with the name.
 func (p *Program) Register(def Def) {
@@ -133,6 +174,58 @@ func (p *Program) Register(def Def) {
 	p.registry[def.Name] = def
 }
 
+// RegisterGroup registers parent as a subcommand under parent.Name, with
+// children appended to its Subcommands, allowing command trees such as
+// "program remote add" and "program remote list". Panics under the same
+// conditions as Register, except that parent.New may be nil: in that case,
+// invoking the group without naming one of children prints the group's own
+// usage (or reports an unknown command, if an unrecognized name was given).
+func (p *Program) RegisterGroup(parent Def, children ...Def) {
+	if parent.Name == "" {
+		panic("empty Name field")
+	}
+	if _, ok := p.registry[parent.Name]; ok {
+		panic("already registered " + parent.Name)
+	}
+	seen := map[string]bool{}
+	for _, child := range parent.Subcommands {
+		seen[child.Name] = true
+	}
+	for _, child := range children {
+		if child.Name == "" {
+			panic("empty Name field")
+		}
+		if child.New == nil {
+			panic("empty New field")
+		}
+		if seen[child.Name] {
+			panic("already registered " + child.Name)
+		}
+		seen[child.Name] = true
+	}
+	parent.Subcommands = append(append([]Def{}, parent.Subcommands...), children...)
+	if parent.New == nil {
+		parent.New = func() Command { return groupCommand{} }
+	}
+	p.registry[parent.Name] = parent
+}
+
+// groupCommand is the default implementation run by a group registered
+// through RegisterGroup that was not given its own New.
+type groupCommand struct{}
+
+func (groupCommand) Run(opt Options) error {
+	if err := opt.ParseFlags(); err != nil {
+		return err
+	}
+	if args := opt.Args(); len(args) > 0 {
+		path := append(append([]string{}, opt.CommandPath...), args[0])
+		return UnknownCommand{Name: strings.Join(path, " ")}
+	}
+	opt.WriteUsageOf(opt.Stderr, opt.Def, opt.CommandPath...)
+	return nil
+}
+
 // Prepare prepares a subcommand. Expects the first argument of p to be the name
 // of a subcommand to run. Returns the name and an input to be passed to the
 // subcommand.
@@ -153,25 +246,48 @@ func (p *Program) Prepare() (name string, input Input) {
 }
 
 // RunWithInput executes the subcommand mapped to the given name with the given
-// input. Returns an UnknownCommand error if the name is not a registered
-// subcommand.
+// input. If the resolved subcommand itself has Subcommands, the leading
+// elements of input.Arguments that name a child are consumed, descending the
+// tree until a name is reached that is not a known child; the remaining
+// arguments are passed to that subcommand. Returns an UnknownCommand error if
+// name is not a registered subcommand.
 func (p *Program) RunWithInput(name string, input Input) error {
-	def := p.registry[name]
-	if def.New == nil {
+	def, path, rest, ok := resolve(p.registry, name, input.Arguments)
+	if !ok {
 		return UnknownCommand{Name: name}
 	}
+	input.Arguments = rest
 	cmd := def.New()
 	opt := Options{
-		FlagSet:  flag.NewFlagSet(p.Program, flag.ContinueOnError),
-		Input:    input,
-		registry: p.registry,
-		Def:      def,
+		commandFlagSet: newFlagSet(p.Program),
+		Input:          input,
+		registry:       p.registry,
+		Def:            def,
+		CommandPath:    path,
 	}
 	opt.SetOutput(io.Discard)
 	if fs, ok := cmd.(FlagSetter); ok {
-		fs.SetFlags(opt.FlagSet)
+		fs.SetFlags(opt.commandFlagSet)
+	}
+	ctx := input.resolvedContext()
+	var err error
+	if p.before != nil {
+		if err = p.before(ctx, opt); err != nil {
+			if p.after != nil {
+				err = p.after(ctx, opt, err)
+			}
+			return err
+		}
 	}
-	return cmd.Run(opt)
+	if cc, ok := cmd.(CommandContext); ok {
+		err = cc.RunContext(ctx, opt)
+	} else {
+		err = cmd.Run(opt)
+	}
+	if p.after != nil {
+		err = p.after(ctx, opt, err)
+	}
+	return err
 }
 
 // Run is like RunWithInput by assuming that the first argument to the program
@@ -185,7 +301,9 @@ func (p *Program) Run(name string) error {
 	return p.RunWithInput(name, input)
 }
 
-// UnknownCommand indicates an unknown subcommand was received.
+// UnknownCommand indicates an unknown subcommand was received. Name is the
+// resolved command path that could not be matched (e.g. "remote foo"), or
+// just the unrecognized name itself if no part of it resolved.
 type UnknownCommand struct {
 	Name string
 }
@@ -208,35 +326,68 @@ func (err UnknownCommand) Error() string {
 // If a subcommand returns an error, then the error is printed to Stderr. If the
 // error is flag.ErrHelp, then a usage message of the command is written to
 // Stderr instead.
+//
+// Main builds a context that is cancelled when the process receives SIGINT
+// or SIGTERM, and runs the subcommand under it (see Input.Context). Once the
+// subcommand finishes, Main resolves an exit code from the result (see
+// ExitCode) and calls os.Exit with it.
 func (p *Program) Main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	p.Input.Context = ctx
+	err := p.dispatch()
+	stop()
+	os.Exit(p.resolveExitCode(err))
+}
+
+// resolveExitCode maps err to a process exit code, using p.exitCode if set.
+func (p *Program) resolveExitCode(err error) int {
+	if p.exitCode != nil {
+		return p.exitCode(err)
+	}
+	if err == nil {
+		return 0
+	}
+	return 1
+}
+
+// dispatch resolves and runs the subcommand named by the program's
+// arguments, as Main does, and returns the resulting error.
+func (p *Program) dispatch() error {
 	if len(p.Arguments) == 0 {
 		if !p.Has("help") {
 			p.WriteUsage(p.Stderr)
-			return
+			return nil
 		}
-		p.run("help")
-		return
+		return p.run("help")
 	}
 	subcommand := p.Arguments[0]
 	if !p.Has(subcommand) {
-		fmt.Fprintln(p.Stderr, UnknownCommand{Name: subcommand}.Error())
+		err := UnknownCommand{Name: subcommand}
+		fmt.Fprintln(p.Stderr, err.Error())
 		p.WriteUsage(p.Stderr)
-		return
+		return err
 	}
-	p.run(subcommand)
+	return p.run(subcommand)
 }
 
-// run runs a subcommand, and prints any resulting errors.
-func (p *Program) run(subcommand string) {
+// run runs a subcommand, prints any resulting error, and returns it.
+func (p *Program) run(subcommand string) error {
 	err := p.Run(subcommand)
 	if err == nil {
-		return
+		return nil
 	}
 	if err == flag.ErrHelp {
-		p.WriteUsageOf(p.Stderr, p.Get(subcommand))
-		return
+		input := p.Input
+		if len(input.Arguments) > 0 {
+			input.Arguments = input.Arguments[1:]
+		}
+		if def, path, _, ok := resolve(p.registry, subcommand, input.Arguments); ok {
+			p.WriteUsageOf(p.Stderr, def, path...)
+		}
+		return err
 	}
 	fmt.Fprintln(p.Stderr, err)
+	return err
 }
 
 // Input contains inputs to a program or subcommand.
@@ -262,28 +413,70 @@ type Input struct {
 	// name, and the second argument is a list of subcommand summaries. If
 	// empty, a default message is displayed.
 	GlobalUsage string
+
+	// Width is the display width, in columns, to wrap usage text to. If 0, it
+	// is detected from Stdout or Stderr when they are a terminal, falling
+	// back to the $COLUMNS environment variable, then to 80.
+	Width int
+
+	// Context is the context a subcommand is run under. Main sets this to a
+	// context that is cancelled when the process receives SIGINT or
+	// SIGTERM. If nil, context.Background is used.
+	Context context.Context
+}
+
+// width returns i.Width, or a detected value if i.Width is 0.
+func (i Input) width() int {
+	if i.Width > 0 {
+		return i.Width
+	}
+	return detectWidth(i)
+}
+
+// resolvedContext returns i.Context, or context.Background if it is nil.
+func (i Input) resolvedContext() context.Context {
+	if i.Context != nil {
+		return i.Context
+	}
+	return context.Background()
 }
 
 // WriteUsageOf writes to w (or Stderr if w is nil) the usage of the given
-// command definition.
-func (i Input) WriteUsageOf(w io.Writer, def Def) {
+// command definition. path, if given, is the sequence of subcommand names
+// leading to def (e.g. []string{"remote", "add"}), used in place of def.Name
+// so that the usage of a nested subcommand is displayed in full.
+//
+// If def has Subcommands, as when it was registered with RegisterGroup, its
+// children are rendered as a subcommand table, mirroring the top-level
+// global usage.
+func (i Input) WriteUsageOf(w io.Writer, def Def, path ...string) {
 	if w == nil {
 		if w = i.Stderr; w == nil {
 			return
 		}
 	}
+	name := def.Name
+	if len(path) > 0 {
+		name = strings.Join(path, " ")
+	}
+	width := i.width()
 	if def.Arguments == "" {
-		fmt.Fprintf(w, "Usage: %s %s\n", i.Program, def.Name)
+		fmt.Fprintf(w, "Usage: %s %s\n", i.Program, name)
 	} else {
-		args := formatDesc(def.Arguments)
-		fmt.Fprintf(w, "Usage: %s %s %s\n", i.Program, def.Name, args)
+		args := formatDesc(def.Arguments, width)
+		fmt.Fprintf(w, "Usage: %s %s %s\n", i.Program, name, args)
 	}
 	if def.Description != "" {
-		desc := formatDesc(def.Description)
+		desc := formatDesc(def.Description, width)
 		fmt.Fprintf(w, "\n%s\n", desc)
 	}
+	if len(def.Subcommands) > 0 {
+		var summaries strings.Builder
+		asRegistry(def.Subcommands).WriteSummary(&summaries, width)
+		fmt.Fprintf(w, "\nThe following commands are available:\n%s", summaries.String())
+	}
 	if fs, ok := def.New().(FlagSetter); ok {
-		flags := flag.NewFlagSet("", flag.ContinueOnError)
+		flags := newFlagSet("")
 		fs.SetFlags(flags)
 		flags.SetOutput(w)
 		fmt.Fprintf(w, "\nFlags:\n")
@@ -303,6 +496,42 @@ type WriteFile interface {
 
 type registry map[string]Def
 
+// asRegistry indexes a list of subcommand definitions by name, so that the
+// Subcommands of a Def can be searched the same way as a Program's
+// top-level registry.
+func asRegistry(defs []Def) registry {
+	r := make(registry, len(defs))
+	for _, def := range defs {
+		r[def.Name] = def
+	}
+	return r
+}
+
+// resolve looks up name in r, then descends into its Subcommands for as
+// long as the next element of args names a child, building up the
+// resolved command path as it goes. It returns the deepest resolved
+// definition, the path of names leading to it, and the arguments left over
+// for that definition to process. ok is false if name is not registered in
+// r at all.
+func resolve(r registry, name string, args []string) (def Def, path []string, rest []string, ok bool) {
+	def, ok = r[name]
+	if !ok {
+		return Def{}, nil, nil, false
+	}
+	path = []string{name}
+	rest = args
+	for len(def.Subcommands) > 0 && len(rest) > 0 {
+		child, found := asRegistry(def.Subcommands)[rest[0]]
+		if !found {
+			break
+		}
+		def = child
+		path = append(path, rest[0])
+		rest = rest[1:]
+	}
+	return def, path, rest, true
+}
+
 // Has returns whether name is a registered subcommand.
 func (r registry) Has(name string) bool {
 	_, ok := r[name]
@@ -327,21 +556,40 @@ func (r registry) List() []Def {
 }
 
 // WriteSummary writes to w a list of each registered subcommand and its
-// summary.
-func (r registry) WriteSummary(w io.Writer) {
+// summary, wrapping summaries to width (a display width in columns, as
+// reported by Input.Width; 0 disables wrapping).
+func (r registry) WriteSummary(w io.Writer, width int) {
 	if w == nil {
 		return
 	}
-	//TODO: Receive width to improve display.
 	list := r.List()
 	nameWidth := 0
 	for _, def := range list {
-		if len(def.Name) > nameWidth {
-			nameWidth = len(def.Name)
+		if def.Hidden {
+			continue
+		}
+		if n := stringWidth(def.Name); n > nameWidth {
+			nameWidth = n
+		}
+	}
+	descWidth := 0
+	if width > 0 {
+		indent := 1 + nameWidth + 4
+		descWidth = width - indent
+		if descWidth < 20 {
+			descWidth = 20
 		}
 	}
 	for _, def := range list {
-		fmt.Fprintf(w, "\t%-*s    %s\n", nameWidth, def.Name, def.Summary)
+		if def.Hidden {
+			continue
+		}
+		lines := strings.Split(wrapText(def.Summary, descWidth), "\n")
+		pad := nameWidth - stringWidth(def.Name)
+		fmt.Fprintf(w, "\t%s%s    %s\n", def.Name, strings.Repeat(" ", pad), lines[0])
+		for _, line := range lines[1:] {
+			fmt.Fprintf(w, "\t%s    %s\n", strings.Repeat(" ", nameWidth), line)
+		}
 	}
 }
 
@@ -353,6 +601,14 @@ type Command interface {
 	Run(Options) error
 }
 
+// CommandContext is implemented by a Command that wants access to the
+// running context.Context, e.g. to honor cancellation of a long-running
+// operation. If a Command implements CommandContext, RunContext is called
+// in place of Run.
+type CommandContext interface {
+	RunContext(context.Context, Options) error
+}
+
 // Def describes a subcommand.
 type Def struct {
 	// Name is the name of the subcommand.
@@ -371,6 +627,29 @@ type Def struct {
 
 	// New returns a new instance of the command.
 	New func() Command
+
+	// CompleteArgs, if not nil, returns completion candidates for a
+	// positional argument of the subcommand. args is the list of words
+	// already present on the command line after the subcommand's path; flags
+	// among them are not parsed or removed. toComplete is the (possibly
+	// empty) portion of the argument currently being completed.
+	CompleteArgs func(opt Options, args []string, toComplete string) []string
+
+	// Hidden excludes the subcommand from summaries and usage listings,
+	// without preventing it from being run. It is intended for
+	// implementation details such as __complete.
+	Hidden bool
+
+	// Subcommands holds the child subcommands of this subcommand, allowing
+	// command trees such as "program remote add" and "program remote list".
+	// Normally populated through RegisterGroup rather than set directly.
+	Subcommands []Def
+
+	// Args validates the positional arguments passed to the subcommand. If
+	// not nil, it is called by Options.ParseFlags after flags have been
+	// parsed; if it returns an error, ParseFlags writes the subcommand's
+	// usage to Stderr and returns the error.
+	Args PositionalArgs
 }
 
 // FlagSetter is implemented by any type that can define flags on a FlagSet.
@@ -399,12 +678,19 @@ type FlagSet interface {
 	Uint64Var(p *uint64, name string, value uint64, usage string)
 	UintVar(p *uint, name string, value uint, usage string)
 	Var(value flag.Value, name string, usage string)
+
+	// RegisterFlagCompletion associates a flag with a function that produces
+	// dynamic completions for its value. fn receives the portion of the value
+	// typed so far and returns the candidates that start with it.
+	RegisterFlagCompletion(name string, fn func(toComplete string) []string)
 }
 
 // Options contains input and flags passed to a subcommand.
 type Options struct {
-	// FlagSet is an embedded set of flags for the subcommand.
-	*flag.FlagSet
+	// *commandFlagSet is embedded anonymously so that its methods (Arg,
+	// Args, NArg, Parse, RegisterFlagCompletion, etc.) are promoted directly
+	// onto Options, e.g. opt.Arg(0).
+	*commandFlagSet
 
 	// Input contains the inputs to the subcommand, with the fields inherited
 	// from Program. The Arguments field is the unprocessed arguments after the
@@ -414,19 +700,39 @@ type Options struct {
 	// Def is the definition of the running command.
 	Def Def
 
+	// CommandPath is the sequence of subcommand names leading to the
+	// running command, e.g. []string{"remote", "add"}.
+	CommandPath []string
+
 	registry
 }
 
-// formatDesc formats a command description for readability.
-func formatDesc(s string) string {
+// formatDesc formats a command description for readability, wrapping it to
+// width (a display width in columns; 0 disables wrapping).
+func formatDesc(s string, width int) string {
 	s = strings.TrimSpace(s)
-	//TODO: Wrap to n characters.
-	return s
+	if width <= 0 {
+		return s
+	}
+	return wrapText(s, width)
 }
 
-// ParseFlags parses the embedded FlagSet using opt.Arguments.
+// ParseFlags parses the embedded FlagSet using opt.Arguments, then, if
+// opt.Def.Args is set, validates the remaining positional arguments against
+// it. If validation fails, the subcommand's usage is written to Stderr
+// before the validation error is returned.
 func (opt Options) ParseFlags() error {
-	return opt.FlagSet.Parse(opt.Arguments)
+	if err := opt.Parse(opt.Arguments); err != nil {
+		return err
+	}
+	if opt.Def.Args == nil {
+		return nil
+	}
+	if err := opt.Def.Args(opt, opt.Args()); err != nil {
+		opt.WriteUsageOf(opt.Stderr, opt.Def, opt.CommandPath...)
+		return err
+	}
+	return nil
 }
 
 // WriteGlobalUsage writes the GlobalUsage message to w, or Stderr if w is nil.
@@ -438,8 +744,8 @@ func (opt Options) WriteGlobalUsage(w io.Writer) {
 var helpDef = Def{
 	Name:        "help",
 	Summary:     "Display help.",
-	Arguments:   "[command]",
-	Description: "Displays help for a command, or general help if no command is given.",
+	Arguments:   "[command...]",
+	Description: "Displays help for a command, or general help if no command is given. For a command with subcommands, give its full path (e.g. \"remote add\").",
 	New:         func() Command { return helpCommand{} },
 }
 
@@ -450,13 +756,14 @@ func (helpCommand) Run(opt Options) error {
 	if err := opt.ParseFlags(); err != nil {
 		return err
 	}
-	if name := opt.Arg(0); name != "" {
-		if opt.Has(name) {
-			opt.WriteUsageOf(opt.Stderr, opt.Get(name))
+	if args := opt.Args(); len(args) > 0 {
+		def, path, rest, ok := resolve(opt.registry, args[0], args[1:])
+		if ok && len(rest) == 0 {
+			opt.WriteUsageOf(opt.Stderr, def, path...)
 		} else {
-			fmt.Fprintln(opt.Stderr, UnknownCommand{Name: name}.Error())
+			fmt.Fprintln(opt.Stderr, UnknownCommand{Name: strings.Join(args, " ")}.Error())
 			fmt.Fprintln(opt.Stderr, "The following commands are available:")
-			opt.WriteSummary(opt.Stderr)
+			opt.WriteSummary(opt.Stderr, opt.width())
 		}
 		return nil
 	}
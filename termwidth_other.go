@@ -0,0 +1,10 @@
+//go:build !unix
+
+package snek
+
+// termWidth returns the width of the terminal associated with f, in
+// columns. Terminal size detection is only implemented for unix platforms,
+// so this always returns 0, deferring to $COLUMNS or the default width.
+func termWidth(f WriteFile) int {
+	return 0
+}
@@ -0,0 +1,31 @@
+//go:build unix
+
+package snek
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// winsize mirrors the kernel's struct winsize, as used by the TIOCGWINSZ
+// ioctl.
+type winsize struct {
+	Row, Col       uint16
+	Xpixel, Ypixel uint16
+}
+
+// termWidth returns the width of the terminal associated with f, in
+// columns, or 0 if it cannot be determined.
+func termWidth(f WriteFile) int {
+	if !isTerminal(f) {
+		return 0
+	}
+	osFile := f.(*os.File)
+	var ws winsize
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, osFile.Fd(), uintptr(syscall.TIOCGWINSZ), uintptr(unsafe.Pointer(&ws)))
+	if errno != 0 {
+		return 0
+	}
+	return int(ws.Col)
+}
@@ -0,0 +1,166 @@
+package snek
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// runeWidthRange describes the display width of a contiguous range of
+// runes.
+type runeWidthRange struct {
+	lo, hi rune
+	width  int
+}
+
+// zeroWidthRanges lists ranges of combining marks and other non-spacing
+// runes, which occupy no columns when rendered.
+var zeroWidthRanges = []runeWidthRange{
+	{0x0300, 0x036F, 0}, // Combining Diacritical Marks
+	{0x0483, 0x0489, 0}, // Combining Cyrillic
+	{0x0591, 0x05BD, 0}, // Hebrew points
+	{0x05BF, 0x05BF, 0},
+	{0x05C1, 0x05C2, 0},
+	{0x05C4, 0x05C5, 0},
+	{0x05C7, 0x05C7, 0},
+	{0x0610, 0x061A, 0}, // Arabic marks
+	{0x064B, 0x065F, 0},
+	{0x0670, 0x0670, 0},
+	{0x06D6, 0x06DC, 0},
+	{0x06DF, 0x06E4, 0},
+	{0x06E7, 0x06E8, 0},
+	{0x06EA, 0x06ED, 0},
+	{0x0E31, 0x0E31, 0}, // Thai
+	{0x0E34, 0x0E3A, 0},
+	{0x0E47, 0x0E4E, 0},
+	{0x1AB0, 0x1AFF, 0}, // Combining Diacritical Marks Extended
+	{0x1DC0, 0x1DFF, 0}, // Combining Diacritical Marks Supplement
+	{0x200B, 0x200F, 0}, // Zero Width Space, joiners, marks
+	{0x202A, 0x202E, 0}, // Directional formatting
+	{0x2060, 0x2064, 0}, // Word joiner and invisible operators
+	{0x20D0, 0x20FF, 0}, // Combining Diacritical Marks for Symbols
+	{0xFE00, 0xFE0F, 0}, // Variation Selectors
+	{0xFE20, 0xFE2F, 0}, // Combining Half Marks
+	{0xFEFF, 0xFEFF, 0}, // Zero Width No-Break Space
+}
+
+// wideRanges lists ranges of East Asian Wide and Fullwidth runes, which
+// occupy two columns when rendered in a monospace font.
+var wideRanges = []runeWidthRange{
+	{0x1100, 0x115F, 2},   // Hangul Jamo
+	{0x2329, 0x232A, 2},   // Angle brackets
+	{0x2E80, 0x303E, 2},   // CJK Radicals .. CJK Symbols and Punctuation
+	{0x3041, 0x33FF, 2},   // Hiragana .. CJK Compatibility
+	{0x3400, 0x4DBF, 2},   // CJK Unified Ideographs Extension A
+	{0x4E00, 0x9FFF, 2},   // CJK Unified Ideographs
+	{0xA000, 0xA4CF, 2},   // Yi Syllables and Radicals
+	{0xAC00, 0xD7A3, 2},   // Hangul Syllables
+	{0xF900, 0xFAFF, 2},   // CJK Compatibility Ideographs
+	{0xFE30, 0xFE4F, 2},   // CJK Compatibility Forms
+	{0xFF00, 0xFF60, 2},   // Fullwidth Forms
+	{0xFFE0, 0xFFE6, 2},   // Fullwidth Signs
+	{0x20000, 0x2FFFD, 2}, // CJK Unified Ideographs Extension B and beyond
+	{0x30000, 0x3FFFD, 2}, // CJK Unified Ideographs Extension G and beyond
+}
+
+// runeWidth returns the display width of r, in columns.
+func runeWidth(r rune) int {
+	if r == 0 {
+		return 0
+	}
+	if r < 0x20 || (r >= 0x7F && r < 0xA0) {
+		return 0
+	}
+	for _, rg := range zeroWidthRanges {
+		if r >= rg.lo && r <= rg.hi {
+			return 0
+		}
+	}
+	for _, rg := range wideRanges {
+		if r >= rg.lo && r <= rg.hi {
+			return 2
+		}
+	}
+	return 1
+}
+
+// stringWidth returns the display width of s, in columns.
+func stringWidth(s string) int {
+	width := 0
+	for _, r := range s {
+		width += runeWidth(r)
+	}
+	return width
+}
+
+// wrapText word-wraps s to width columns, preserving existing newlines as
+// paragraph breaks. If width is not positive, s is returned unmodified.
+func wrapText(s string, width int) string {
+	if width <= 0 {
+		return s
+	}
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = wrapLine(line, width)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// wrapLine word-wraps a single line (containing no newlines) to width
+// columns.
+func wrapLine(line string, width int) string {
+	words := strings.Fields(line)
+	if len(words) == 0 {
+		return ""
+	}
+	var out strings.Builder
+	lineWidth := 0
+	for i, word := range words {
+		wordWidth := stringWidth(word)
+		if i > 0 {
+			if lineWidth+1+wordWidth > width {
+				out.WriteByte('\n')
+				lineWidth = 0
+			} else {
+				out.WriteByte(' ')
+				lineWidth++
+			}
+		}
+		out.WriteString(word)
+		lineWidth += wordWidth
+	}
+	return out.String()
+}
+
+// detectWidth determines a terminal width to wrap usage text to, preferring
+// Stdout, then Stderr, then the $COLUMNS environment variable, and finally
+// falling back to 80.
+func detectWidth(i Input) int {
+	if w := termWidth(i.Stdout); w > 0 {
+		return w
+	}
+	if w := termWidth(i.Stderr); w > 0 {
+		return w
+	}
+	if columns := os.Getenv("COLUMNS"); columns != "" {
+		if n, err := strconv.Atoi(columns); err == nil && n > 0 {
+			return n
+		}
+	}
+	return 80
+}
+
+// isTerminal returns whether f refers to an *os.File connected to a
+// character device, which is a reasonable proxy for being a terminal
+// without relying on a platform-specific dependency.
+func isTerminal(f WriteFile) bool {
+	osFile, ok := f.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := osFile.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
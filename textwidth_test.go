@@ -0,0 +1,42 @@
+package snek
+
+import "testing"
+
+func TestStringWidth(t *testing.T) {
+	tests := []struct {
+		s    string
+		want int
+	}{
+		{"", 0},
+		{"abc", 3},
+		{"日本語", 6},
+		{"ábc", 3}, // combining acute accent is zero-width
+		{"héllo", 5},
+	}
+	for _, test := range tests {
+		if got := stringWidth(test.s); got != test.want {
+			t.Errorf("stringWidth(%q): got %d, want %d", test.s, got, test.want)
+		}
+	}
+}
+
+func TestWrapText(t *testing.T) {
+	tests := []struct {
+		s     string
+		width int
+		want  string
+	}{
+		{"one two three four", 0, "one two three four"},
+		{"one two three four", -1, "one two three four"},
+		{"one two three four", 11, "one two\nthree four"},
+		{"one two three four", 100, "one two three four"},
+		{"first line\nsecond line", 10, "first line\nsecond\nline"},
+		{"", 10, ""},
+		{"   ", 10, ""},
+	}
+	for _, test := range tests {
+		if got := wrapText(test.s, test.width); got != test.want {
+			t.Errorf("wrapText(%q, %d): got %q, want %q", test.s, test.width, got, test.want)
+		}
+	}
+}